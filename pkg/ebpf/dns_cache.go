@@ -1,6 +1,7 @@
 package ebpf
 
 import (
+	"container/list"
 	"sort"
 	"sync"
 	"sync/atomic"
@@ -10,50 +11,65 @@ import (
 	"github.com/DataDog/datadog-agent/pkg/util/log"
 )
 
+// dnsCacheGrace bounds how far a still-in-use entry can be pushed out past
+// "now" on a cache hit, so a busy connection to a short-lived record doesn't
+// keep that record resolvable long after its real DNS TTL expired.
+const dnsCacheGrace = 30 * time.Second
+
 type reverseDNSCache struct {
-	mux  sync.Mutex
-	data map[util.Address]*dnsCacheVal
-	exit chan struct{}
+	mux sync.Mutex
+	// data indexes into lru, which keeps entries ordered from most- to
+	// least-recently-used so we know what to evict under size pressure.
+	data map[util.Address]*list.Element
+	lru  *list.List
 	ttl  time.Duration
 	size int
 
 	// Telemetry
-	len      int64
-	lookups  int64
-	resolved int64
+	len          int64
+	lookups      int64
+	resolved     int64
+	evictions    int64
+	rejectedAdds int64
 }
 
 type translation struct {
 	name string
 	ips  map[util.Address]struct{}
+	// ttl is the cache duration derived from the DNS response that produced
+	// this translation (see dnsParser.Parse). A zero value means the caller
+	// didn't have per-record TTL information and the cache's default should
+	// be used instead.
+	ttl time.Duration
+}
+
+func newTranslation(name string) *translation {
+	return &translation{name: name, ips: make(map[util.Address]struct{})}
+}
+
+func (t *translation) add(addr util.Address) {
+	t.ips[addr] = struct{}{}
 }
 
 type cacheStats struct {
-	lookups  int64
-	resolved int64
-	len      int64
+	lookups        int64
+	resolved       int64
+	len            int64
+	evictions      int64
+	rejectedAdds   int64
+	oldestEntryAge int64
 }
 
-func newReverseDNSCache(size int, ttl, expirationPeriod time.Duration) *reverseDNSCache {
-	cache := &reverseDNSCache{
-		data: make(map[util.Address]*dnsCacheVal),
-		exit: make(chan struct{}),
+// newReverseDNSCache builds an empty cache. Expiration is driven externally
+// (see SocketFilterSnooper, which ticks both this cache and forwardDNSCache
+// off of a single shared goroutine) by calling expire() periodically.
+func newReverseDNSCache(size int, ttl time.Duration) *reverseDNSCache {
+	return &reverseDNSCache{
+		data: make(map[util.Address]*list.Element),
+		lru:  list.New(),
 		ttl:  ttl,
 		size: size,
 	}
-
-	ticker := time.NewTicker(expirationPeriod)
-	go func() {
-		for {
-			select {
-			case <-ticker.C:
-				cache.expire()
-			case <-cache.exit:
-				return
-			}
-		}
-	}()
-	return cache
 }
 
 func (c *reverseDNSCache) Add(translation *translation, now time.Time) bool {
@@ -63,25 +79,57 @@ func (c *reverseDNSCache) Add(translation *translation, now time.Time) bool {
 
 	c.mux.Lock()
 	defer c.mux.Unlock()
-	if len(c.data) >= c.size {
-		return false
+
+	ttl := translation.ttl
+	if ttl <= 0 {
+		ttl = c.ttl
 	}
+	exp := now.Add(ttl).Unix()
 
-	exp := now.Add(c.ttl).Unix()
+	added := false
 	for addr := range translation.ips {
-		val, ok := c.data[addr]
-		if ok {
+		if elem, ok := c.data[addr]; ok {
+			val := elem.Value.(*dnsCacheVal)
 			val.expiration = exp
+			val.ttl = ttl
 			val.merge(translation.name)
+			c.lru.MoveToFront(elem)
+			added = true
+			continue
+		}
+
+		if len(c.data) >= c.size && !c.evictOldest() {
+			// Cache is full and there was nothing left to evict (shouldn't
+			// happen in practice, but don't let a new translation get lost
+			// silently).
+			atomic.AddInt64(&c.rejectedAdds, 1)
 			continue
 		}
 
-		c.data[addr] = &dnsCacheVal{names: []string{translation.name}, expiration: exp}
+		val := &dnsCacheVal{addr: addr, names: []string{translation.name}, expiration: exp, ttl: ttl, added: now.Unix()}
+		c.data[addr] = c.lru.PushFront(val)
+		added = true
 	}
 
 	// Update cache length for telemetry purposes
 	atomic.StoreInt64(&c.len, int64(len(c.data)))
 
+	return added
+}
+
+// evictOldest removes the least-recently-used entry to make room for a new
+// translation. The caller must hold c.mux. Returns false if the cache is
+// empty (nothing to evict).
+func (c *reverseDNSCache) evictOldest() bool {
+	elem := c.lru.Back()
+	if elem == nil {
+		return false
+	}
+
+	val := elem.Value.(*dnsCacheVal)
+	delete(c.data, val.addr)
+	c.lru.Remove(elem)
+	atomic.AddInt64(&c.evictions, 1)
 	return true
 }
 
@@ -91,15 +139,14 @@ func (c *reverseDNSCache) Get(conns []ConnectionStats, now time.Time) []NamePair
 	}
 
 	names := make([]NamePair, len(conns))
-	expiration := now.Add(c.ttl).Unix()
 
 	lookups := len(conns)
 	resolved := 0
 
 	c.mux.Lock()
 	for i, conn := range conns {
-		names[i].Source = c.getNamesForIP(conn.Source, expiration)
-		names[i].Dest = c.getNamesForIP(conn.Dest, expiration)
+		names[i].Source = c.getNamesForIP(conn.Source, now)
+		names[i].Dest = c.getNamesForIP(conn.Dest, now)
 
 		// Track number of successful resolutions for destination IP only
 		if names[i].Dest != nil {
@@ -121,43 +168,76 @@ func (c *reverseDNSCache) Len() int {
 
 func (c *reverseDNSCache) Stats() cacheStats {
 	var (
-		lookups  = atomic.SwapInt64(&c.lookups, 0)
-		resolved = atomic.SwapInt64(&c.resolved, 0)
+		lookups      = atomic.SwapInt64(&c.lookups, 0)
+		resolved     = atomic.SwapInt64(&c.resolved, 0)
+		evictions    = atomic.SwapInt64(&c.evictions, 0)
+		rejectedAdds = atomic.SwapInt64(&c.rejectedAdds, 0)
 	)
 
+	c.mux.Lock()
+	oldestAge := c.oldestEntryAge(time.Now())
+	c.mux.Unlock()
+
 	return cacheStats{
-		lookups:  lookups,
-		resolved: resolved,
-		len:      int64(c.Len()),
+		lookups:        lookups,
+		resolved:       resolved,
+		len:            int64(c.Len()),
+		evictions:      evictions,
+		rejectedAdds:   rejectedAdds,
+		oldestEntryAge: oldestAge,
 	}
 }
 
-func (c *reverseDNSCache) Close() {
-	c.exit <- struct{}{}
+// oldestEntryAge returns, in seconds, the age of the least-recently-used
+// entry still resident in the cache. The caller must hold c.mux.
+func (c *reverseDNSCache) oldestEntryAge(now time.Time) int64 {
+	elem := c.lru.Back()
+	if elem == nil {
+		return 0
+	}
+
+	val := elem.Value.(*dnsCacheVal)
+	return now.Unix() - val.added
 }
 
-func (c *reverseDNSCache) getNamesForIP(ip util.Address, expiration int64) []string {
-	val, ok := c.data[ip]
+// getNamesForIP returns the cached names for ip, if any. A connection actively
+// using the address only earns it a bounded "grace" bump of min(ttl, 30s) past
+// now, rather than perpetually refreshing it back out to the full DNS TTL -
+// otherwise a chatty connection would keep a stale entry alive indefinitely.
+// The caller must hold c.mux.
+func (c *reverseDNSCache) getNamesForIP(ip util.Address, now time.Time) []string {
+	elem, ok := c.data[ip]
 	if !ok {
 		return nil
 	}
+	val := elem.Value.(*dnsCacheVal)
+
+	grace := val.ttl
+	if grace > dnsCacheGrace {
+		grace = dnsCacheGrace
+	}
+	if graceExp := now.Add(grace).Unix(); graceExp > val.expiration {
+		val.expiration = graceExp
+	}
+	c.lru.MoveToFront(elem)
 
-	val.expiration = expiration
 	return val.copy()
 }
 
 func (c *reverseDNSCache) expire() {
 	expired := 0
 	start := time.Now()
-	deadline := start.Add(-c.ttl).Unix()
+	deadline := start.Unix()
 
 	c.mux.Lock()
-	for addr, val := range c.data {
+	for addr, elem := range c.data {
+		val := elem.Value.(*dnsCacheVal)
 		if val.expiration > deadline {
 			continue
 		}
 
 		expired++
+		c.lru.Remove(elem)
 		delete(c.data, addr)
 	}
 	total := len(c.data)
@@ -171,9 +251,19 @@ func (c *reverseDNSCache) expire() {
 }
 
 type dnsCacheVal struct {
+	addr util.Address
 	// opting for a []string instead of map[string]struct{} since common case is len(names) == 1
 	names      []string
 	expiration int64
+	// ttl is the cache duration this entry was added (or last refreshed) with,
+	// derived from the authoritative DNS response. It bounds the grace period
+	// getNamesForIP is allowed to extend the entry by on subsequent hits.
+	ttl time.Duration
+	// added is the unix timestamp this entry was first inserted, used to
+	// compute oldest_entry_age_seconds telemetry. It is intentionally left
+	// untouched by refreshes so it reflects how long the address has been
+	// resident, not how recently it was last seen.
+	added int64
 }
 
 func (v *dnsCacheVal) merge(name string) {
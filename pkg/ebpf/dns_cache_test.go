@@ -0,0 +1,293 @@
+package ebpf
+
+import (
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/DataDog/datadog-agent/pkg/process/util"
+)
+
+// BenchmarkReverseDNSCacheLRUContention exercises Add/Get concurrently, the
+// way SocketFilterSnooper.pollPackets (writer) and Resolve (reader) do, to
+// make sure the LRU bookkeeping doesn't turn the cache lock into a
+// bottleneck once eviction starts kicking in.
+func BenchmarkReverseDNSCacheLRUContention(b *testing.B) {
+	const cacheSize = 1000
+	cache := newReverseDNSCache(cacheSize, 100*time.Millisecond)
+
+	addrs := make([]util.Address, cacheSize*2)
+	for i := range addrs {
+		addrs[i] = util.AddressFromString("10.0." + strconv.Itoa(i/256) + "." + strconv.Itoa(i%256))
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	stop := make(chan struct{})
+	go func() {
+		defer wg.Done()
+		i := 0
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			t := newTranslation("host-" + strconv.Itoa(i%len(addrs)) + ".example.com")
+			t.add(addrs[i%len(addrs)])
+			cache.Add(t, time.Now())
+			i++
+		}
+	}()
+
+	conns := make([]ConnectionStats, 100)
+	for i := range conns {
+		conns[i] = ConnectionStats{Source: addrs[i], Dest: addrs[len(addrs)-1-i]}
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		cache.Get(conns, time.Now())
+	}
+	b.StopTimer()
+
+	close(stop)
+	wg.Wait()
+}
+
+func TestClampTTL(t *testing.T) {
+	tests := []struct {
+		name                   string
+		ttl, floor, ceil, want time.Duration
+	}{
+		{"within bounds", 10 * time.Second, 5 * time.Second, time.Minute, 10 * time.Second},
+		{"below floor", 2 * time.Second, 5 * time.Second, time.Minute, 5 * time.Second},
+		{"above ceiling", 2 * time.Hour, 5 * time.Second, time.Hour, time.Hour},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := clampTTL(tt.ttl, tt.floor, tt.ceil); got != tt.want {
+				t.Fatalf("clampTTL(%s, %s, %s) = %s, want %s", tt.ttl, tt.floor, tt.ceil, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestReverseDNSCacheAddHonorsPerRecordTTL asserts that a translation
+// carrying its own TTL (as produced by dnsParser.Parse from the DNS
+// response's answer records) sets the cache entry's expiration from that
+// TTL, not from the cache's default.
+func TestReverseDNSCacheAddHonorsPerRecordTTL(t *testing.T) {
+	cache := newReverseDNSCache(10, time.Minute)
+	addr := util.AddressFromString("1.2.3.4")
+	now := time.Unix(1000, 0)
+
+	trans := newTranslation("short-lived.example.com")
+	trans.add(addr)
+	trans.ttl = 5 * time.Second
+
+	if !cache.Add(trans, now) {
+		t.Fatalf("expected Add to succeed")
+	}
+
+	elem, ok := cache.data[addr]
+	if !ok {
+		t.Fatalf("expected entry for %s", addr)
+	}
+
+	val := elem.Value.(*dnsCacheVal)
+	if want := now.Add(5 * time.Second).Unix(); val.expiration != want {
+		t.Fatalf("expiration = %d, want %d (per-record TTL, not cache default)", val.expiration, want)
+	}
+	if val.ttl != 5*time.Second {
+		t.Fatalf("ttl = %s, want 5s", val.ttl)
+	}
+}
+
+// TestReverseDNSCacheAddFallsBackToDefaultTTL covers the translation.ttl <= 0
+// case, i.e. a caller with no per-record TTL information.
+func TestReverseDNSCacheAddFallsBackToDefaultTTL(t *testing.T) {
+	cache := newReverseDNSCache(10, 42*time.Second)
+	addr := util.AddressFromString("1.2.3.5")
+	now := time.Unix(2000, 0)
+
+	trans := newTranslation("no-ttl.example.com")
+	trans.add(addr)
+
+	cache.Add(trans, now)
+
+	val := cache.data[addr].Value.(*dnsCacheVal)
+	if want := now.Add(42 * time.Second).Unix(); val.expiration != want {
+		t.Fatalf("expiration = %d, want %d (cache default TTL)", val.expiration, want)
+	}
+}
+
+// TestReverseDNSCacheGetNamesForIPGraceBump covers the bounded "grace" bump
+// getNamesForIP grants on a hit: the entry's own (short) TTL, not the full
+// cache default.
+func TestReverseDNSCacheGetNamesForIPGraceBump(t *testing.T) {
+	cache := newReverseDNSCache(10, time.Minute)
+	addr := util.AddressFromString("1.2.3.6")
+	now := time.Unix(3000, 0)
+
+	trans := newTranslation("short.example.com")
+	trans.add(addr)
+	trans.ttl = 5 * time.Second
+	cache.Add(trans, now)
+
+	later := now.Add(time.Minute)
+	cache.mux.Lock()
+	names := cache.getNamesForIP(addr, later)
+	cache.mux.Unlock()
+
+	if len(names) != 1 || names[0] != "short.example.com" {
+		t.Fatalf("unexpected names: %v", names)
+	}
+
+	val := cache.data[addr].Value.(*dnsCacheVal)
+	if want := later.Add(5 * time.Second).Unix(); val.expiration != want {
+		t.Fatalf("expiration after grace bump = %d, want %d (bounded by record ttl)", val.expiration, want)
+	}
+}
+
+// TestReverseDNSCacheGetNamesForIPGraceBumpCappedAtMax covers the other half
+// of the bound: a long-lived record's grace bump is capped at dnsCacheGrace,
+// not refreshed out to its full TTL.
+func TestReverseDNSCacheGetNamesForIPGraceBumpCappedAtMax(t *testing.T) {
+	cache := newReverseDNSCache(10, time.Minute)
+	addr := util.AddressFromString("1.2.3.7")
+	now := time.Unix(4000, 0)
+
+	trans := newTranslation("long.example.com")
+	trans.add(addr)
+	trans.ttl = time.Hour
+	cache.Add(trans, now)
+
+	// Hit the entry once its own 1h expiration has been reached, so the
+	// grace bump is what decides the new expiration.
+	later := now.Add(time.Hour)
+	cache.mux.Lock()
+	cache.getNamesForIP(addr, later)
+	cache.mux.Unlock()
+
+	val := cache.data[addr].Value.(*dnsCacheVal)
+	if want := later.Add(dnsCacheGrace).Unix(); val.expiration != want {
+		t.Fatalf("expiration after grace bump = %d, want %d (capped at dnsCacheGrace, not full 1h ttl)", val.expiration, want)
+	}
+}
+
+// TestReverseDNSCacheLRUEviction asserts that filling a cache past its size
+// evicts the least-recently-used entry, not an arbitrary one - and that a
+// read via getNamesForIP counts as a use that protects an entry from
+// eviction.
+func TestReverseDNSCacheLRUEviction(t *testing.T) {
+	cache := newReverseDNSCache(2, time.Minute)
+	now := time.Unix(5000, 0)
+
+	a1 := util.AddressFromString("10.0.0.1")
+	a2 := util.AddressFromString("10.0.0.2")
+	a3 := util.AddressFromString("10.0.0.3")
+
+	add := func(addr util.Address, name string) {
+		trans := newTranslation(name)
+		trans.add(addr)
+		cache.Add(trans, now)
+	}
+
+	add(a1, "a1.example.com")
+	add(a2, "a2.example.com")
+
+	// Touch a1 so a2 becomes the least-recently-used entry.
+	cache.mux.Lock()
+	cache.getNamesForIP(a1, now)
+	cache.mux.Unlock()
+
+	// Cache is full (size 2); adding a3 must evict a2, not a1.
+	add(a3, "a3.example.com")
+
+	cache.mux.Lock()
+	_, hasA1 := cache.data[a1]
+	_, hasA2 := cache.data[a2]
+	_, hasA3 := cache.data[a3]
+	cache.mux.Unlock()
+
+	if !hasA1 || hasA2 || !hasA3 {
+		t.Fatalf("expected a1 and a3 resident and a2 evicted, got a1=%v a2=%v a3=%v", hasA1, hasA2, hasA3)
+	}
+
+	if evictions := atomic.LoadInt64(&cache.evictions); evictions != 1 {
+		t.Fatalf("evictions = %d, want 1", evictions)
+	}
+}
+
+// TestReverseDNSCacheRejectsAddsWhenFull covers the case evictOldest can't
+// free up room (size 0): the translation is dropped and counted via
+// rejectedAdds rather than silently growing the cache past its configured
+// size.
+func TestReverseDNSCacheRejectsAddsWhenFull(t *testing.T) {
+	cache := newReverseDNSCache(0, time.Minute)
+	now := time.Unix(6000, 0)
+	addr := util.AddressFromString("10.0.0.9")
+
+	trans := newTranslation("never-fits.example.com")
+	trans.add(addr)
+
+	if added := cache.Add(trans, now); added {
+		t.Fatalf("expected Add to report nothing added when the cache can't make room")
+	}
+
+	if rejected := atomic.LoadInt64(&cache.rejectedAdds); rejected != 1 {
+		t.Fatalf("rejectedAdds = %d, want 1", rejected)
+	}
+}
+
+// TestReverseDNSCacheStats covers the lookups/resolved/len counters
+// surfaced via Stats.
+func TestReverseDNSCacheStats(t *testing.T) {
+	cache := newReverseDNSCache(10, time.Minute)
+	now := time.Unix(7000, 0)
+	addr := util.AddressFromString("10.0.0.10")
+	other := util.AddressFromString("10.0.0.11")
+
+	trans := newTranslation("counted.example.com")
+	trans.add(addr)
+	cache.Add(trans, now)
+
+	conns := []ConnectionStats{{Dest: addr}, {Dest: other}}
+	cache.Get(conns, now)
+
+	stats := cache.Stats()
+	if stats.lookups != 2 {
+		t.Fatalf("lookups = %d, want 2", stats.lookups)
+	}
+	if stats.resolved != 1 {
+		t.Fatalf("resolved = %d, want 1", stats.resolved)
+	}
+	if stats.len != 1 {
+		t.Fatalf("len = %d, want 1", stats.len)
+	}
+}
+
+// TestReverseDNSCacheOldestEntryAge covers oldestEntryAge directly, since
+// Stats() pins it to time.Now() and can't be driven deterministically from
+// a test.
+func TestReverseDNSCacheOldestEntryAge(t *testing.T) {
+	cache := newReverseDNSCache(10, time.Minute)
+	start := time.Unix(8000, 0)
+	addr := util.AddressFromString("10.0.0.12")
+
+	trans := newTranslation("aged.example.com")
+	trans.add(addr)
+	cache.Add(trans, start)
+
+	cache.mux.Lock()
+	age := cache.oldestEntryAge(start.Add(90 * time.Second))
+	cache.mux.Unlock()
+
+	if age != 90 {
+		t.Fatalf("oldestEntryAge = %d, want 90", age)
+	}
+}
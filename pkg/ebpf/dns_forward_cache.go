@@ -0,0 +1,161 @@
+package ebpf
+
+import (
+	"container/list"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/DataDog/datadog-agent/pkg/process/util"
+)
+
+// forwardDNSCache answers "which IPs does hostname X currently resolve to?",
+// the mirror image of reverseDNSCache. It's populated from the exact same
+// parsed DNS answers as the reverse cache (see
+// SocketFilterSnooper.processPacket); since dnsParser.Parse already
+// associates the original question name with the final A/AAAA targets of
+// the response, CNAME chains arrive here already flattened - a lookup for
+// the original QNAME returns the ultimate addresses directly.
+//
+// It follows the same bounded-size LRU strategy as reverseDNSCache, and
+// shares its expiration goroutine (see SocketFilterSnooper).
+type forwardDNSCache struct {
+	mux  sync.Mutex
+	data map[string]*list.Element
+	lru  *list.List
+	ttl  time.Duration
+	size int
+
+	// Telemetry
+	len      int64
+	lookups  int64
+	resolved int64
+}
+
+type forwardCacheVal struct {
+	host       string
+	ips        map[util.Address]struct{}
+	expiration int64
+}
+
+func newForwardDNSCache(size int, ttl time.Duration) *forwardDNSCache {
+	return &forwardDNSCache{
+		data: make(map[string]*list.Element),
+		lru:  list.New(),
+		ttl:  ttl,
+		size: size,
+	}
+}
+
+// Add records that translation.name currently resolves to translation.ips,
+// merging with whatever is already cached for that host. A translation with
+// no ips (e.g. a CNAME-only answer with no accompanying A/AAAA) is a no-op,
+// so a CNAME chain that hasn't resolved to an address yet doesn't burn a
+// cache slot or make Get return a non-nil-but-empty result for it.
+func (c *forwardDNSCache) Add(translation *translation, now time.Time) bool {
+	if translation == nil || translation.name == "" || len(translation.ips) == 0 {
+		return false
+	}
+
+	host := normalizeHost(translation.name)
+	ttl := translation.ttl
+	if ttl <= 0 {
+		ttl = c.ttl
+	}
+	exp := now.Add(ttl).Unix()
+
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	if elem, ok := c.data[host]; ok {
+		val := elem.Value.(*forwardCacheVal)
+		val.expiration = exp
+		for addr := range translation.ips {
+			val.ips[addr] = struct{}{}
+		}
+		c.lru.MoveToFront(elem)
+		atomic.StoreInt64(&c.len, int64(len(c.data)))
+		return true
+	}
+
+	if len(c.data) >= c.size {
+		if elem := c.lru.Back(); elem != nil {
+			stale := elem.Value.(*forwardCacheVal)
+			delete(c.data, stale.host)
+			c.lru.Remove(elem)
+		}
+	}
+
+	ips := make(map[util.Address]struct{}, len(translation.ips))
+	for addr := range translation.ips {
+		ips[addr] = struct{}{}
+	}
+	c.data[host] = c.lru.PushFront(&forwardCacheVal{host: host, ips: ips, expiration: exp})
+
+	atomic.StoreInt64(&c.len, int64(len(c.data)))
+	return true
+}
+
+// Get returns the IPs currently associated with host, or nil if unknown.
+func (c *forwardDNSCache) Get(host string, now time.Time) []util.Address {
+	host = normalizeHost(host)
+
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	atomic.AddInt64(&c.lookups, 1)
+
+	elem, ok := c.data[host]
+	if !ok {
+		return nil
+	}
+
+	val := elem.Value.(*forwardCacheVal)
+	c.lru.MoveToFront(elem)
+
+	addrs := make([]util.Address, 0, len(val.ips))
+	for addr := range val.ips {
+		addrs = append(addrs, addr)
+	}
+
+	atomic.AddInt64(&c.resolved, 1)
+	return addrs
+}
+
+func (c *forwardDNSCache) Len() int {
+	return int(atomic.LoadInt64(&c.len))
+}
+
+func (c *forwardDNSCache) Stats() cacheStats {
+	return cacheStats{
+		lookups:  atomic.SwapInt64(&c.lookups, 0),
+		resolved: atomic.SwapInt64(&c.resolved, 0),
+		len:      int64(c.Len()),
+	}
+}
+
+func (c *forwardDNSCache) expire() {
+	deadline := time.Now().Unix()
+
+	c.mux.Lock()
+	for host, elem := range c.data {
+		val := elem.Value.(*forwardCacheVal)
+		if val.expiration > deadline {
+			continue
+		}
+
+		c.lru.Remove(elem)
+		delete(c.data, host)
+	}
+	total := len(c.data)
+	c.mux.Unlock()
+
+	atomic.StoreInt64(&c.len, int64(total))
+}
+
+// normalizeHost lowercases and strips the trailing root-zone dot from a DNS
+// name so "Example.com." and "example.com" are treated as the same key.
+func normalizeHost(host string) string {
+	return strings.ToLower(strings.TrimSuffix(host, "."))
+}
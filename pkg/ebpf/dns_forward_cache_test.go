@@ -0,0 +1,154 @@
+package ebpf
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DataDog/datadog-agent/pkg/process/util"
+)
+
+func TestNormalizeHost(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"Example.com", "example.com"},
+		{"example.com.", "example.com"},
+		{"EXAMPLE.COM.", "example.com"},
+		{"example.com", "example.com"},
+	}
+
+	for _, tt := range tests {
+		if got := normalizeHost(tt.in); got != tt.want {
+			t.Fatalf("normalizeHost(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+// TestForwardDNSCacheAddAndGet covers the basic round-trip, including that
+// lookups are case- and trailing-dot-insensitive.
+func TestForwardDNSCacheAddAndGet(t *testing.T) {
+	cache := newForwardDNSCache(10, time.Minute)
+	now := time.Unix(1000, 0)
+	addr := util.AddressFromString("10.1.1.1")
+
+	trans := newTranslation("Example.com.")
+	trans.add(addr)
+	if !cache.Add(trans, now) {
+		t.Fatalf("expected Add to succeed")
+	}
+
+	ips := cache.Get("example.com", now)
+	if len(ips) != 1 || ips[0] != addr {
+		t.Fatalf("Get returned %v, want [%v]", ips, addr)
+	}
+}
+
+// TestForwardDNSCacheCNAMEFlattening covers the case documented on
+// forwardDNSCache: a CNAME chain is recorded under the original question
+// name, flattened to the ultimate A/AAAA addresses, since dnsParser.Parse
+// already did the flattening before the translation reaches the cache.
+func TestForwardDNSCacheCNAMEFlattening(t *testing.T) {
+	cache := newForwardDNSCache(10, time.Minute)
+	now := time.Unix(2000, 0)
+	addr := util.AddressFromString("10.1.1.2")
+
+	// www.example.com CNAME edge.example.net, edge.example.net A 10.1.1.2 -
+	// dnsParser.Parse associates the original question name directly with
+	// the final address.
+	trans := newTranslation("www.example.com")
+	trans.add(addr)
+	cache.Add(trans, now)
+
+	if ips := cache.Get("www.example.com", now); len(ips) != 1 || ips[0] != addr {
+		t.Fatalf("Get(www.example.com) = %v, want [%v]", ips, addr)
+	}
+	if ips := cache.Get("edge.example.net", now); ips != nil {
+		t.Fatalf("Get(edge.example.net) = %v, want nil (only the question name is keyed)", ips)
+	}
+}
+
+// TestForwardDNSCacheAddMergesRepeatedHost covers a host resolving to
+// multiple addresses across separate responses (e.g. round-robin DNS).
+func TestForwardDNSCacheAddMergesRepeatedHost(t *testing.T) {
+	cache := newForwardDNSCache(10, time.Minute)
+	now := time.Unix(3000, 0)
+	a1 := util.AddressFromString("10.1.1.3")
+	a2 := util.AddressFromString("10.1.1.4")
+
+	first := newTranslation("multi.example.com")
+	first.add(a1)
+	cache.Add(first, now)
+
+	second := newTranslation("multi.example.com")
+	second.add(a2)
+	cache.Add(second, now)
+
+	ips := cache.Get("multi.example.com", now)
+	if len(ips) != 2 {
+		t.Fatalf("Get returned %d ips, want 2: %v", len(ips), ips)
+	}
+}
+
+// TestForwardDNSCacheAddIgnoresEmptyTranslation covers a CNAME-only answer
+// (no accompanying A/AAAA in the same response): translation.ips is empty,
+// and Add must no-op rather than caching an empty result that a caller of
+// Get could misread as "resolved to nothing."
+func TestForwardDNSCacheAddIgnoresEmptyTranslation(t *testing.T) {
+	cache := newForwardDNSCache(10, time.Minute)
+	now := time.Unix(6000, 0)
+
+	trans := newTranslation("cname-only.example.com")
+
+	if added := cache.Add(trans, now); added {
+		t.Fatalf("expected Add to no-op for a translation with no ips")
+	}
+	if cache.Len() != 0 {
+		t.Fatalf("Len() = %d, want 0", cache.Len())
+	}
+	if ips := cache.Get("cname-only.example.com", now); ips != nil {
+		t.Fatalf("Get = %v, want nil (unknown, not resolved-to-nothing)", ips)
+	}
+}
+
+func TestForwardDNSCacheGetUnknownHost(t *testing.T) {
+	cache := newForwardDNSCache(10, time.Minute)
+	if ips := cache.Get("never-seen.example.com", time.Unix(4000, 0)); ips != nil {
+		t.Fatalf("Get returned %v for an unknown host, want nil", ips)
+	}
+}
+
+// TestForwardDNSCacheExpire covers that expire() drops entries whose
+// expiration has passed and leaves others untouched.
+func TestForwardDNSCacheExpire(t *testing.T) {
+	cache := newForwardDNSCache(10, time.Minute)
+	now := time.Unix(5000, 0)
+
+	shortLived := newTranslation("short.example.com")
+	shortLived.add(util.AddressFromString("10.1.1.5"))
+	shortLived.ttl = time.Second
+	cache.Add(shortLived, now)
+
+	longLived := newTranslation("long.example.com")
+	longLived.add(util.AddressFromString("10.1.1.6"))
+	longLived.ttl = time.Hour
+	cache.Add(longLived, now)
+
+	// expire() deadlines against time.Now(), so backdate both entries'
+	// expirations relative to now instead of trying to fast-forward the
+	// clock.
+	realNow := time.Now()
+	cache.mux.Lock()
+	cache.data["short.example.com"].Value.(*forwardCacheVal).expiration = realNow.Add(-time.Second).Unix()
+	cache.data["long.example.com"].Value.(*forwardCacheVal).expiration = realNow.Add(time.Hour).Unix()
+	cache.mux.Unlock()
+
+	cache.expire()
+
+	if ips := cache.Get("short.example.com", now); ips != nil {
+		t.Fatalf("expected short.example.com to have expired, got %v", ips)
+	}
+	if ips := cache.Get("long.example.com", now); ips == nil {
+		t.Fatalf("expected long.example.com to still be cached")
+	}
+	if cache.Len() != 1 {
+		t.Fatalf("Len() = %d, want 1", cache.Len())
+	}
+}
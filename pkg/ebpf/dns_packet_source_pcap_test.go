@@ -0,0 +1,140 @@
+package ebpf
+
+import (
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/DataDog/datadog-agent/pkg/process/util"
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcapgo"
+)
+
+// buildDNSResponse serializes a minimal A-record DNS response answering
+// question with ip, the same shape dnsParser.Parse expects - the socket
+// filter already strips the packet down to the DNS payload before handing
+// it to PacketSource, so no Ethernet/IP/UDP framing is needed here.
+func buildDNSResponse(t *testing.T, question string, ip net.IP, ttl uint32) []byte {
+	t.Helper()
+
+	dns := layers.DNS{
+		ID:      1,
+		QR:      true,
+		OpCode:  layers.DNSOpCodeQuery,
+		QDCount: 1,
+		ANCount: 1,
+		Questions: []layers.DNSQuestion{
+			{Name: []byte(question), Type: layers.DNSTypeA, Class: layers.DNSClassIN},
+		},
+		Answers: []layers.DNSResourceRecord{
+			{Name: []byte(question), Type: layers.DNSTypeA, Class: layers.DNSClassIN, TTL: ttl, IP: ip},
+		},
+	}
+
+	buf := gopacket.NewSerializeBuffer()
+	if err := dns.SerializeTo(buf, gopacket.SerializeOptions{}); err != nil {
+		t.Fatalf("serializing dns response: %s", err)
+	}
+
+	return buf.Bytes()
+}
+
+func writeTestPCAP(t *testing.T, payloads ...[]byte) string {
+	t.Helper()
+
+	f, err := os.CreateTemp("", "dns-snooper-*.pcap")
+	if err != nil {
+		t.Fatalf("creating temp pcap file: %s", err)
+	}
+	defer f.Close()
+
+	writer := pcapgo.NewWriter(f)
+	if err := writer.WriteFileHeader(65536, layers.LinkTypeEthernet); err != nil {
+		t.Fatalf("writing pcap header: %s", err)
+	}
+
+	for _, payload := range payloads {
+		ci := gopacket.CaptureInfo{Timestamp: time.Now(), CaptureLength: len(payload), Length: len(payload)}
+		if err := writer.WritePacket(ci, payload); err != nil {
+			t.Fatalf("writing pcap packet: %s", err)
+		}
+	}
+
+	return f.Name()
+}
+
+// TestSocketFilterSnooperFromPCAPReplaysEveryPacket exercises the pcap-replay
+// PacketSource end to end: the snooper should consume every packet in the
+// file and feed it through the same parsing path as the live eBPF source, so
+// each of the two non-DNS payloads is counted as one decoding error.
+func TestSocketFilterSnooperFromPCAPReplaysEveryPacket(t *testing.T) {
+	path := writeTestPCAP(t, []byte("not a dns packet"), []byte("also not a dns packet"))
+	defer os.Remove(path)
+
+	snooper, err := NewSocketFilterSnooperFromPCAP(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer snooper.Close()
+
+	// GetStats resets its counters on every call, so sum them up.
+	var decodingErrors int64
+	for i := 0; i < 50; i++ {
+		decodingErrors += snooper.GetStats()["decoding_errors"]
+		if decodingErrors >= 2 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if decodingErrors != 2 {
+		t.Fatalf("expected one decoding error per non-DNS payload (2), got %d", decodingErrors)
+	}
+}
+
+// TestSocketFilterSnooperFromPCAPPopulatesCachesFromRealDNSResponse replays a
+// well-formed DNS response and asserts it reaches both the reverse and
+// forward caches, proving the pcap-replay path actually drives the same
+// parsing/caching logic the live eBPF source does rather than only
+// exercising the decoding-error counter.
+func TestSocketFilterSnooperFromPCAPPopulatesCachesFromRealDNSResponse(t *testing.T) {
+	ip := net.ParseIP("93.184.216.34").To4()
+	payload := buildDNSResponse(t, "example.com", ip, 30)
+
+	path := writeTestPCAP(t, payload)
+	defer os.Remove(path)
+
+	snooper, err := NewSocketFilterSnooperFromPCAP(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer snooper.Close()
+
+	addr := util.AddressFromNetIP(ip)
+
+	var names []NamePair
+	for i := 0; i < 50; i++ {
+		names = snooper.reverse.Get([]ConnectionStats{{Dest: addr}}, time.Now())
+		if len(names) == 1 && len(names[0].Dest) > 0 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if len(names) != 1 || len(names[0].Dest) != 1 || names[0].Dest[0] != "example.com" {
+		t.Fatalf("expected reverse cache to resolve %s to example.com, got %+v", addr, names)
+	}
+
+	var hostIPs []util.Address
+	for i := 0; i < 50; i++ {
+		hostIPs = snooper.ResolveHost("example.com")
+		if len(hostIPs) > 0 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if len(hostIPs) != 1 || hostIPs[0] != addr {
+		t.Fatalf("expected forward cache to resolve example.com to %s, got %v", addr, hostIPs)
+	}
+}
@@ -0,0 +1,87 @@
+package ebpf
+
+import (
+	"time"
+
+	"github.com/DataDog/datadog-agent/pkg/process/util"
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+// dnsParser extracts DNS translations out of raw packet data captured by the
+// socket filter snooper; each translation feeds both the reverse and the
+// forward DNS cache.
+type dnsParser struct {
+	decoded []gopacket.LayerType
+	layer   gopacket.DecodingLayerParser
+	dns     layers.DNS
+	minTTL  time.Duration
+	maxTTL  time.Duration
+}
+
+func newDNSParser(minTTL, maxTTL time.Duration) *dnsParser {
+	return &dnsParser{
+		decoded: make([]gopacket.LayerType, 0, 10),
+		layer:   *gopacket.NewDecodingLayerParser(layers.LayerTypeDNS, &layers.DNS{}),
+		minTTL:  minTTL,
+		maxTTL:  maxTTL,
+	}
+}
+
+// Parse decodes a raw DNS response packet and, if it carries A/AAAA/CNAME
+// answers, returns the resulting translation along with the TTL the entry
+// should be cached for. The TTL is the minimum TTL across all the answer
+// records in the response, clamped to [p.minTTL, p.maxTTL]. Parse returns nil
+// for DNS queries (as opposed to responses) and for anything that doesn't
+// decode as DNS.
+func (p *dnsParser) Parse(data []byte) *translation {
+	if err := p.layer.DecodeLayers(data, &p.decoded); err != nil {
+		return nil
+	}
+
+	if !p.dns.QR || len(p.dns.Questions) == 0 {
+		return nil
+	}
+
+	var (
+		t      *translation
+		minTTL = p.maxTTL
+	)
+
+	for _, record := range p.dns.Answers {
+		switch record.Type {
+		case layers.DNSTypeA, layers.DNSTypeAAAA:
+			if t == nil {
+				t = newTranslation(string(p.dns.Questions[0].Name))
+			}
+			t.add(util.AddressFromNetIP(record.IP))
+		case layers.DNSTypeCNAME:
+			if t == nil {
+				t = newTranslation(string(p.dns.Questions[0].Name))
+			}
+		default:
+			continue
+		}
+
+		if recordTTL := time.Duration(record.TTL) * time.Second; recordTTL < minTTL {
+			minTTL = recordTTL
+		}
+	}
+
+	if t == nil {
+		return nil
+	}
+
+	t.ttl = clampTTL(minTTL, p.minTTL, p.maxTTL)
+	return t
+}
+
+func clampTTL(ttl, floor, ceiling time.Duration) time.Duration {
+	if ttl < floor {
+		return floor
+	}
+	if ttl > ceiling {
+		return ceiling
+	}
+	return ttl
+}
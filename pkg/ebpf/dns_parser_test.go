@@ -0,0 +1,185 @@
+package ebpf
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/DataDog/datadog-agent/pkg/process/util"
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+func serializeDNS(t *testing.T, dns layers.DNS) []byte {
+	t.Helper()
+
+	buf := gopacket.NewSerializeBuffer()
+	if err := dns.SerializeTo(buf, gopacket.SerializeOptions{}); err != nil {
+		t.Fatalf("serializing dns packet: %s", err)
+	}
+
+	return buf.Bytes()
+}
+
+func aRecord(name string, ip net.IP, ttl uint32) layers.DNSResourceRecord {
+	return layers.DNSResourceRecord{Name: []byte(name), Type: layers.DNSTypeA, Class: layers.DNSClassIN, TTL: ttl, IP: ip.To4()}
+}
+
+func cnameRecord(name, target string, ttl uint32) layers.DNSResourceRecord {
+	return layers.DNSResourceRecord{Name: []byte(name), Type: layers.DNSTypeCNAME, Class: layers.DNSClassIN, TTL: ttl, CNAME: []byte(target)}
+}
+
+// TestDNSParserParseMultipleAnswersMinTTLWins covers a response with
+// several A answers carrying different TTLs: the translation's TTL must be
+// the minimum across all of them, not the first or last one seen.
+func TestDNSParserParseMultipleAnswersMinTTLWins(t *testing.T) {
+	parser := newDNSParser(5*time.Second, time.Hour)
+
+	question := "multi.example.com"
+	ip1 := net.ParseIP("10.0.0.1")
+	ip2 := net.ParseIP("10.0.0.2")
+
+	dns := layers.DNS{
+		QR:      true,
+		QDCount: 1,
+		ANCount: 2,
+		Questions: []layers.DNSQuestion{
+			{Name: []byte(question), Type: layers.DNSTypeA, Class: layers.DNSClassIN},
+		},
+		Answers: []layers.DNSResourceRecord{
+			aRecord(question, ip1, 120),
+			aRecord(question, ip2, 30),
+		},
+	}
+
+	trans := parser.Parse(serializeDNS(t, dns))
+	if trans == nil {
+		t.Fatalf("expected a translation")
+	}
+	if trans.name != question {
+		t.Fatalf("name = %q, want %q", trans.name, question)
+	}
+	if trans.ttl != 30*time.Second {
+		t.Fatalf("ttl = %s, want 30s (minimum across answers)", trans.ttl)
+	}
+	if _, ok := trans.ips[util.AddressFromNetIP(ip1.To4())]; !ok {
+		t.Fatalf("expected %s in translation", ip1)
+	}
+	if _, ok := trans.ips[util.AddressFromNetIP(ip2.To4())]; !ok {
+		t.Fatalf("expected %s in translation", ip2)
+	}
+}
+
+// TestDNSParserParseClampsTTL covers the floor/ceiling clamp being applied
+// through Parse, not just in clampTTL's own unit tests.
+func TestDNSParserParseClampsTTL(t *testing.T) {
+	question := "clamped.example.com"
+	ip := net.ParseIP("10.0.0.3")
+
+	t.Run("below floor", func(t *testing.T) {
+		parser := newDNSParser(10*time.Second, time.Hour)
+		dns := layers.DNS{
+			QR:        true,
+			QDCount:   1,
+			ANCount:   1,
+			Questions: []layers.DNSQuestion{{Name: []byte(question), Type: layers.DNSTypeA, Class: layers.DNSClassIN}},
+			Answers:   []layers.DNSResourceRecord{aRecord(question, ip, 1)},
+		}
+
+		trans := parser.Parse(serializeDNS(t, dns))
+		if trans == nil {
+			t.Fatalf("expected a translation")
+		}
+		if trans.ttl != 10*time.Second {
+			t.Fatalf("ttl = %s, want 10s floor", trans.ttl)
+		}
+	})
+
+	t.Run("above ceiling", func(t *testing.T) {
+		parser := newDNSParser(5*time.Second, time.Minute)
+		dns := layers.DNS{
+			QR:        true,
+			QDCount:   1,
+			ANCount:   1,
+			Questions: []layers.DNSQuestion{{Name: []byte(question), Type: layers.DNSTypeA, Class: layers.DNSClassIN}},
+			Answers:   []layers.DNSResourceRecord{aRecord(question, ip, 3600)},
+		}
+
+		trans := parser.Parse(serializeDNS(t, dns))
+		if trans == nil {
+			t.Fatalf("expected a translation")
+		}
+		if trans.ttl != time.Minute {
+			t.Fatalf("ttl = %s, want 1m ceiling", trans.ttl)
+		}
+	})
+}
+
+// TestDNSParserParseCNAMEChainKeyedOnQuestionName covers a CNAME+A chain:
+// the translation must be keyed on the original question name and carry
+// the final (CNAME target's) address, with the TTL taking the minimum
+// across both records.
+func TestDNSParserParseCNAMEChainKeyedOnQuestionName(t *testing.T) {
+	parser := newDNSParser(5*time.Second, time.Hour)
+
+	question := "www.example.com"
+	cnameTarget := "edge.example.net"
+	ip := net.ParseIP("10.0.0.4")
+
+	dns := layers.DNS{
+		QR:        true,
+		QDCount:   1,
+		ANCount:   2,
+		Questions: []layers.DNSQuestion{{Name: []byte(question), Type: layers.DNSTypeA, Class: layers.DNSClassIN}},
+		Answers: []layers.DNSResourceRecord{
+			cnameRecord(question, cnameTarget, 300),
+			aRecord(cnameTarget, ip, 60),
+		},
+	}
+
+	trans := parser.Parse(serializeDNS(t, dns))
+	if trans == nil {
+		t.Fatalf("expected a translation")
+	}
+	if trans.name != question {
+		t.Fatalf("name = %q, want %q (keyed on the question, not the CNAME target)", trans.name, question)
+	}
+	if len(trans.ips) != 1 {
+		t.Fatalf("expected exactly one address, got %d: %v", len(trans.ips), trans.ips)
+	}
+	if _, ok := trans.ips[util.AddressFromNetIP(ip.To4())]; !ok {
+		t.Fatalf("expected final address %s in translation", ip)
+	}
+	if trans.ttl != 60*time.Second {
+		t.Fatalf("ttl = %s, want 60s (minimum across CNAME and A records)", trans.ttl)
+	}
+}
+
+func TestDNSParserParseReturnsNilForQueries(t *testing.T) {
+	parser := newDNSParser(5*time.Second, time.Hour)
+	dns := layers.DNS{
+		QR:        false,
+		QDCount:   1,
+		Questions: []layers.DNSQuestion{{Name: []byte("query.example.com"), Type: layers.DNSTypeA, Class: layers.DNSClassIN}},
+	}
+
+	if trans := parser.Parse(serializeDNS(t, dns)); trans != nil {
+		t.Fatalf("expected nil for a DNS query (QR=false), got %+v", trans)
+	}
+}
+
+func TestDNSParserParseReturnsNilForEmptyQuestions(t *testing.T) {
+	parser := newDNSParser(5*time.Second, time.Hour)
+	dns := layers.DNS{QR: true}
+
+	if trans := parser.Parse(serializeDNS(t, dns)); trans != nil {
+		t.Fatalf("expected nil when the response carries no questions, got %+v", trans)
+	}
+}
+
+func TestDNSParserParseReturnsNilForUndecodableData(t *testing.T) {
+	parser := newDNSParser(5*time.Second, time.Hour)
+	if trans := parser.Parse([]byte("not a dns packet")); trans != nil {
+		t.Fatalf("expected nil for undecodable data, got %+v", trans)
+	}
+}
@@ -1,36 +1,71 @@
-// +build linux_bpf
-
 package ebpf
 
 import (
-	"fmt"
-	"reflect"
+	"io"
 	"sync"
 	"sync/atomic"
 	"syscall"
 	"time"
 
+	"github.com/DataDog/datadog-agent/pkg/config"
 	"github.com/DataDog/datadog-agent/pkg/process/util"
 	"github.com/DataDog/datadog-agent/pkg/util/log"
-	"github.com/google/gopacket/afpacket"
-	bpflib "github.com/iovisor/gobpf/elf"
+	"github.com/google/gopacket"
 )
 
 const (
 	dnsCacheTTL              = 3 * time.Minute
 	dnsCacheExpirationPeriod = 1 * time.Minute
 	dnsCacheSize             = 100000
+
+	// dnsCacheMinTTL/dnsCacheMaxTTL bound the per-record TTL honored from DNS
+	// responses, so a misbehaving nameserver can't pin an entry forever (or
+	// force us to re-resolve on every packet).
+	dnsCacheMinTTL = 5 * time.Second
+	dnsCacheMaxTTL = 1 * time.Hour
 )
 
 var _ ReverseDNS = &SocketFilterSnooper{}
 
-// SocketFilterSnooper is a DNS traffic snooper built on top of an eBPF SOCKET_FILTER
+// PacketSourceStats mirrors the poll/packet counters SocketFilterSnooper's
+// telemetry needs, decoupled from any particular capture backend.
+type PacketSourceStats struct {
+	Polls   int64
+	Packets int64
+}
+
+// PacketSourceSocketStats mirrors the kernel-level capture/drop counters
+// telemetry needs. The live eBPF source backs these with real socket
+// statistics; a replayed pcap has no equivalent and reports zeros.
+type PacketSourceSocketStats struct {
+	Captured int64
+	Dropped  int64
+}
+
+// PacketSource abstracts how SocketFilterSnooper gets raw packet data, so the
+// DNS parsing/caching path can be driven either by a live eBPF socket filter
+// (packetSource, linux_bpf builds) or by replaying a .pcap capture
+// (pcapPacketSource) for deterministic tests and field-issue reproduction.
+// Its stats types are source-owned rather than borrowed from afpacket, so
+// this file (and anything that only needs the interface, like the pcap
+// backend) has no dependency on a Linux-only capture package.
+type PacketSource interface {
+	// ZeroCopyReadPacketData reads the next packet. The returned slice is
+	// owned by the source and is invalidated by the next call.
+	ZeroCopyReadPacketData() (data []byte, ci gopacket.CaptureInfo, err error)
+	Stats() (PacketSourceStats, error)
+	SocketStats() (PacketSourceSocketStats, error)
+	Close()
+}
+
+// SocketFilterSnooper is a DNS traffic snooper built on top of a PacketSource
 type SocketFilterSnooper struct {
-	source *packetSource
-	parser *dnsParser
-	cache  *reverseDNSCache
-	exit   chan struct{}
-	wg     sync.WaitGroup
+	source  PacketSource
+	parser  *dnsParser
+	reverse *reverseDNSCache
+	forward *forwardDNSCache
+	exit    chan struct{}
+	wg      sync.WaitGroup
 
 	// packet telemetry
 	captured       int64
@@ -40,19 +75,17 @@ type SocketFilterSnooper struct {
 	decodingErrors int64
 }
 
-// NewSocketFilterSnooper returns a new SocketFilterSnooper
-func NewSocketFilterSnooper(filter *bpflib.SocketFilter) (*SocketFilterSnooper, error) {
-	packetSrc, err := newPacketSource(filter)
-	if err != nil {
-		return nil, err
-	}
-
-	cache := newReverseDNSCache(dnsCacheSize, dnsCacheTTL, dnsCacheExpirationPeriod)
+// newSocketFilterSnooper wires up a SocketFilterSnooper around an already
+// constructed PacketSource, shared by both the live eBPF constructor and the
+// pcap-replay one.
+func newSocketFilterSnooper(source PacketSource) *SocketFilterSnooper {
+	size, ttl := configuredDNSCacheSize(), configuredDNSCacheTTL()
 	snooper := &SocketFilterSnooper{
-		source: packetSrc,
-		parser: newDNSParser(),
-		cache:  cache,
-		exit:   make(chan struct{}),
+		source:  source,
+		parser:  newDNSParser(configuredDNSCacheMinTTL(), configuredDNSCacheMaxTTL()),
+		reverse: newReverseDNSCache(size, ttl),
+		forward: newForwardDNSCache(size, ttl),
+		exit:    make(chan struct{}),
 	}
 
 	// Start consuming packets
@@ -69,36 +102,116 @@ func NewSocketFilterSnooper(filter *bpflib.SocketFilter) (*SocketFilterSnooper,
 		snooper.wg.Done()
 	}()
 
-	return snooper, nil
+	// Both caches share a single expiration goroutine/period
+	snooper.wg.Add(1)
+	go func() {
+		snooper.expireDNSCaches(configuredDNSCacheExpirationPeriod())
+		snooper.wg.Done()
+	}()
+
+	return snooper
+}
+
+func (s *SocketFilterSnooper) expireDNSCaches(period time.Duration) {
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.reverse.expire()
+			s.forward.expire()
+		case <-s.exit:
+			return
+		}
+	}
+}
+
+// configuredDNSCacheSize returns the maximum number of entries each DNS cache
+// (reverse and forward) retains, allowing operators to trade off memory footprint for
+// how quickly the cache fills up in busy DNS environments.
+func configuredDNSCacheSize() int {
+	if size := config.Datadog.GetInt("system_probe_config.dns_cache_size"); size > 0 {
+		return size
+	}
+	return dnsCacheSize
+}
+
+// configuredDNSCacheTTL returns the fallback TTL used for translations that
+// don't carry their own per-record TTL (see dnsParser.Parse).
+func configuredDNSCacheTTL() time.Duration {
+	if ttl := config.Datadog.GetDuration("system_probe_config.dns_cache_ttl"); ttl > 0 {
+		return ttl
+	}
+	return dnsCacheTTL
+}
+
+func configuredDNSCacheExpirationPeriod() time.Duration {
+	if period := config.Datadog.GetDuration("system_probe_config.dns_cache_expiration_period"); period > 0 {
+		return period
+	}
+	return dnsCacheExpirationPeriod
+}
+
+// configuredDNSCacheMinTTL/configuredDNSCacheMaxTTL bound the per-record TTL
+// honored from DNS responses (see dnsParser.Parse), so operators can tune
+// the floor/ceiling instead of being stuck with the package defaults.
+func configuredDNSCacheMinTTL() time.Duration {
+	if ttl := config.Datadog.GetDuration("system_probe_config.dns_cache_min_ttl"); ttl > 0 {
+		return ttl
+	}
+	return dnsCacheMinTTL
+}
+
+func configuredDNSCacheMaxTTL() time.Duration {
+	if ttl := config.Datadog.GetDuration("system_probe_config.dns_cache_max_ttl"); ttl > 0 {
+		return ttl
+	}
+	return dnsCacheMaxTTL
 }
 
 // Resolve IPs to Names
 func (s *SocketFilterSnooper) Resolve(connections []ConnectionStats) map[util.Address][]string {
-	return s.cache.Get(connections, time.Now())
+	return s.reverse.Get(connections, time.Now())
 }
 
-func (s *SocketFilterSnooper) GetStats() map[string]int64 {
-	stats := s.cache.Stats()
-	stats["socket_polls"] = atomic.SwapInt64(&s.polls, 0)
-	stats["packets_processed"] = atomic.SwapInt64(&s.processed, 0)
-	stats["packets_captured"] = atomic.SwapInt64(&s.captured, 0)
-	stats["packets_dropped"] = atomic.SwapInt64(&s.dropped, 0)
-	stats["decoding_errors"] = atomic.SwapInt64(&s.decodingErrors, 0)
+// ResolveHost returns the IPs that host currently resolves to, according to
+// forward DNS traffic observed by the snooper, or nil if unknown.
+func (s *SocketFilterSnooper) ResolveHost(host string) []util.Address {
+	return s.forward.Get(host, time.Now())
+}
 
-	return stats
+func (s *SocketFilterSnooper) GetStats() map[string]int64 {
+	reverseStats := s.reverse.Stats()
+	forwardStats := s.forward.Stats()
+	return map[string]int64{
+		"lookups":                  reverseStats.lookups,
+		"resolved":                 reverseStats.resolved,
+		"dns_cache_len":            reverseStats.len,
+		"dns_cache_evictions":      reverseStats.evictions,
+		"dns_cache_rejected_adds":  reverseStats.rejectedAdds,
+		"oldest_entry_age_seconds": reverseStats.oldestEntryAge,
+		"forward_lookups":          forwardStats.lookups,
+		"forward_resolved":         forwardStats.resolved,
+		"forward_len":              forwardStats.len,
+		"socket_polls":             atomic.SwapInt64(&s.polls, 0),
+		"packets_processed":        atomic.SwapInt64(&s.processed, 0),
+		"packets_captured":         atomic.SwapInt64(&s.captured, 0),
+		"packets_dropped":          atomic.SwapInt64(&s.dropped, 0),
+		"decoding_errors":          atomic.SwapInt64(&s.decodingErrors, 0),
+	}
 }
 
-// Close terminates the DNS traffic snooper as well as the underlying socket and the attached filter
+// Close terminates the DNS traffic snooper as well as the underlying packet source
 func (s *SocketFilterSnooper) Close() {
 	close(s.exit)
 	s.wg.Wait()
 	s.source.Close()
-	s.cache.Close()
 }
 
 // processPacket retrieves DNS information from the received packet data and adds it to
-// the reverse DNS cache. The underlying packet data can't be referenced after this method
-// call since gopacket re-uses it.
+// the reverse and forward DNS caches. The underlying packet data can't be referenced
+// after this method call since gopacket re-uses it.
 func (s *SocketFilterSnooper) processPacket(data []byte) {
 	translation := s.parser.Parse(data)
 	if translation == nil {
@@ -106,7 +219,9 @@ func (s *SocketFilterSnooper) processPacket(data []byte) {
 		return
 	}
 
-	s.cache.Add(translation, time.Now())
+	now := time.Now()
+	s.reverse.Add(translation, now)
+	s.forward.Add(translation, now)
 }
 
 func (s *SocketFilterSnooper) pollPackets() {
@@ -125,6 +240,12 @@ func (s *SocketFilterSnooper) pollPackets() {
 			continue
 		}
 
+		// The source has nothing left to give us (e.g. a pcap replay that
+		// reached the end of the file) - there's no point spinning.
+		if err == io.EOF {
+			return
+		}
+
 		// Immediately retry for EAGAIN
 		if err == syscall.EAGAIN {
 			continue
@@ -149,8 +270,12 @@ func (s *SocketFilterSnooper) pollStats() {
 	for {
 		select {
 		case <-ticker.C:
-			sourceStats, _ := s.source.Stats()
-			_, socketStats, err := s.source.SocketStats()
+			sourceStats, err := s.source.Stats()
+			if err != nil {
+				log.Errorf("error polling source stats: %s", err)
+				continue
+			}
+			socketStats, err := s.source.SocketStats()
 			if err != nil {
 				log.Errorf("error polling socket stats: %s", err)
 				continue
@@ -158,58 +283,15 @@ func (s *SocketFilterSnooper) pollStats() {
 
 			atomic.AddInt64(&s.polls, sourceStats.Polls-prevPolls)
 			atomic.AddInt64(&s.processed, sourceStats.Packets-prevProcessed)
-			atomic.AddInt64(&s.captured, int64(socketStats.Packets())-prevCaptured)
-			atomic.AddInt64(&s.dropped, int64(socketStats.Drops())-prevDropped)
+			atomic.AddInt64(&s.captured, socketStats.Captured-prevCaptured)
+			atomic.AddInt64(&s.dropped, socketStats.Dropped-prevDropped)
 
 			prevPolls = sourceStats.Polls
 			prevProcessed = sourceStats.Packets
-			prevCaptured = int64(socketStats.Packets())
-			prevDropped = int64(socketStats.Drops())
+			prevCaptured = socketStats.Captured
+			prevDropped = socketStats.Dropped
 		case <-s.exit:
 			return
 		}
 	}
 }
-
-// packetSource provides a RAW_SOCKET attached to an eBPF SOCKET_FILTER
-type packetSource struct {
-	*afpacket.TPacket
-	socketFilter *bpflib.SocketFilter
-	socketFD     int
-}
-
-func newPacketSource(filter *bpflib.SocketFilter) (*packetSource, error) {
-	rawSocket, err := afpacket.NewTPacket(
-		afpacket.OptPollTimeout(1*time.Second),
-		// This setup will require ~4Mb that is mmap'd into the process virtual space
-		// More information here: https://www.kernel.org/doc/Documentation/networking/packet_mmap.txt
-		afpacket.OptFrameSize(4096),
-		afpacket.OptBlockSize(4096*128),
-		afpacket.OptNumBlocks(8),
-	)
-	if err != nil {
-		return nil, fmt.Errorf("error creating raw socket: %s", err)
-	}
-
-	// The underlying socket file descriptor is private, hence the use of reflection
-	socketFD := int(reflect.ValueOf(rawSocket).Elem().FieldByName("fd").Int())
-
-	// Attaches DNS socket filter to the RAW_SOCKET
-	if err := bpflib.AttachSocketFilter(filter, socketFD); err != nil {
-		return nil, fmt.Errorf("error attaching filter to socket: %s", err)
-	}
-
-	return &packetSource{
-		TPacket:      rawSocket,
-		socketFilter: filter,
-		socketFD:     socketFD,
-	}, nil
-}
-
-func (p *packetSource) Close() {
-	if err := bpflib.DetachSocketFilter(p.socketFilter, p.socketFD); err != nil {
-		log.Errorf("error detaching socket filter: %s", err)
-	}
-
-	p.TPacket.Close()
-}
@@ -0,0 +1,96 @@
+// +build linux_bpf
+
+package ebpf
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+	"github.com/google/gopacket/afpacket"
+	bpflib "github.com/iovisor/gobpf/elf"
+)
+
+// NewSocketFilterSnooper returns a new SocketFilterSnooper backed by a live
+// eBPF SOCKET_FILTER. This is the default PacketSource in production; see
+// NewSocketFilterSnooperFromPCAP for the pcap-replay one used in tests.
+func NewSocketFilterSnooper(filter *bpflib.SocketFilter) (*SocketFilterSnooper, error) {
+	packetSrc, err := newPacketSource(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	return newSocketFilterSnooper(packetSrc), nil
+}
+
+// packetSource provides a RAW_SOCKET attached to an eBPF SOCKET_FILTER
+type packetSource struct {
+	*afpacket.TPacket
+	socketFilter *bpflib.SocketFilter
+	socketFD     int
+}
+
+var _ PacketSource = &packetSource{}
+
+func newPacketSource(filter *bpflib.SocketFilter) (*packetSource, error) {
+	rawSocket, err := afpacket.NewTPacket(
+		afpacket.OptPollTimeout(1*time.Second),
+		// This setup will require ~4Mb that is mmap'd into the process virtual space
+		// More information here: https://www.kernel.org/doc/Documentation/networking/packet_mmap.txt
+		afpacket.OptFrameSize(4096),
+		afpacket.OptBlockSize(4096*128),
+		afpacket.OptNumBlocks(8),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error creating raw socket: %s", err)
+	}
+
+	// The underlying socket file descriptor is private, hence the use of reflection
+	socketFD := int(reflect.ValueOf(rawSocket).Elem().FieldByName("fd").Int())
+
+	// Attaches DNS socket filter to the RAW_SOCKET
+	if err := bpflib.AttachSocketFilter(filter, socketFD); err != nil {
+		return nil, fmt.Errorf("error attaching filter to socket: %s", err)
+	}
+
+	return &packetSource{
+		TPacket:      rawSocket,
+		socketFilter: filter,
+		socketFD:     socketFD,
+	}, nil
+}
+
+// Stats adapts afpacket.Stats to the source-owned PacketSourceStats so this
+// type satisfies PacketSource without forcing afpacket on every consumer of
+// that interface.
+func (p *packetSource) Stats() (PacketSourceStats, error) {
+	stats, err := p.TPacket.Stats()
+	if err != nil {
+		return PacketSourceStats{}, err
+	}
+
+	return PacketSourceStats{Polls: stats.Polls, Packets: stats.Packets}, nil
+}
+
+// SocketStats adapts afpacket's v3 socket statistics to the source-owned
+// PacketSourceSocketStats.
+func (p *packetSource) SocketStats() (PacketSourceSocketStats, error) {
+	_, socketStatsV3, err := p.TPacket.SocketStats()
+	if err != nil {
+		return PacketSourceSocketStats{}, err
+	}
+
+	return PacketSourceSocketStats{
+		Captured: int64(socketStatsV3.Packets()),
+		Dropped:  int64(socketStatsV3.Drops()),
+	}, nil
+}
+
+func (p *packetSource) Close() {
+	if err := bpflib.DetachSocketFilter(p.socketFilter, p.socketFD); err != nil {
+		log.Errorf("error detaching socket filter: %s", err)
+	}
+
+	p.TPacket.Close()
+}
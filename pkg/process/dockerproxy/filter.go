@@ -5,6 +5,7 @@ package dockerproxy
 import (
 	"strconv"
 	"strings"
+	"sync"
 
 	model "github.com/DataDog/agent-payload/process"
 	"github.com/DataDog/datadog-agent/pkg/util/log"
@@ -13,10 +14,13 @@ import (
 
 // Filter keeps track of every docker-proxy instance and filters network traffic going through them
 type Filter struct {
+	mux           sync.Mutex
 	proxyByTarget map[target]*proxy
 
 	// This "secondary index" is used only during the proxy IP discovery process
 	proxyByPID map[int32]*proxy
+
+	watcher *processWatcher
 }
 
 type target struct {
@@ -29,9 +33,17 @@ type proxy struct {
 	pid    int32
 	ip     string
 	target target
+
+	// startTime is the process' starttime (in clock ticks since boot), used
+	// by processWatcher to detect PID reuse. It's left at zero if we failed
+	// to read it at discovery time, in which case the watcher can only rely
+	// on plain liveness.
+	startTime uint64
 }
 
-// NewFilter instantiates a new filter loaded with docker-proxy instance information
+// NewFilter instantiates a new filter loaded with docker-proxy instance
+// information and starts a background watcher that keeps it up to date as
+// proxies come and go.
 func NewFilter() *Filter {
 	filter := new(Filter)
 	if procs, err := process.AllProcesses(); err == nil {
@@ -40,11 +52,31 @@ func NewFilter() *Filter {
 		log.Errorf("error initiating proxy filter: %s", err)
 	}
 
+	filter.watcher = newProcessWatcher(filter)
 	return filter
 }
 
+// Close stops the background proxy liveness watcher.
+func (f *Filter) Close() {
+	if f.watcher != nil {
+		f.watcher.Close()
+	}
+}
+
+// Stats returns counters for proxies added/removed/stale since the last
+// call, so regressions in the background watcher are observable.
+func (f *Filter) Stats() map[string]int64 {
+	if f.watcher == nil {
+		return nil
+	}
+	return f.watcher.Stats()
+}
+
 // LoadProxies by inspecting processes information
 func (f *Filter) LoadProxies(procs map[int32]*process.FilledProcess) {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+
 	f.proxyByPID = make(map[int32]*proxy)
 	f.proxyByTarget = make(map[target]*proxy)
 
@@ -69,6 +101,9 @@ func (f *Filter) LoadProxies(procs map[int32]*process.FilledProcess) {
 
 // Filter all connections that have a docker-proxy at one end
 func (f *Filter) Filter(payload *model.Connections) {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+
 	if len(f.proxyByPID) == 0 {
 		return
 	}
@@ -137,6 +172,9 @@ func extractProxyTarget(p *process.FilledProcess) *proxy {
 
 	// Extract proxy target address
 	proxy := &proxy{pid: p.Pid}
+	if startTime, ok := processStartTime(p.Pid); ok {
+		proxy.startTime = startTime
+	}
 	for i := 0; i < len(p.Cmdline)-1; i++ {
 		switch p.Cmdline[i] {
 		case "-container-ip":
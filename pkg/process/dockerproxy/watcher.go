@@ -0,0 +1,204 @@
+// +build !windows
+
+package dockerproxy
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+	"github.com/DataDog/gopsutil/process"
+)
+
+// watcherPollInterval is how often the watcher checks proxy liveness and
+// scans for newly spawned docker-proxy instances.
+const watcherPollInterval = 2 * time.Second
+
+// processWatcher keeps a Filter's proxy set in sync with the real world.
+// `Filter.proxyByPID`/`Filter.proxyByTarget` are otherwise only populated
+// once at startup, so without this a container restart, a new port binding,
+// or a killed proxy would silently produce wrong filtering results until the
+// agent itself was restarted.
+//
+// A watcher is, loosely, a "process coroner": every tick it compares the
+// (pid, starttime) pair it recorded for each known proxy against /proc to
+// detect both plain exits and PID reuse by an unrelated process, and it
+// rescans running processes for new docker-proxy binaries.
+type processWatcher struct {
+	filter *Filter
+	exit   chan struct{}
+	wg     sync.WaitGroup
+
+	// Telemetry
+	added   int64
+	removed int64
+	stale   int64
+}
+
+func newProcessWatcher(filter *Filter) *processWatcher {
+	w := &processWatcher{filter: filter, exit: make(chan struct{})}
+
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		w.run()
+	}()
+
+	return w
+}
+
+func (w *processWatcher) run() {
+	ticker := time.NewTicker(watcherPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			w.reapStale()
+			w.discoverNew()
+		case <-w.exit:
+			return
+		}
+	}
+}
+
+// Close stops the watcher goroutine.
+func (w *processWatcher) Close() {
+	close(w.exit)
+	w.wg.Wait()
+}
+
+// Stats returns counters for proxies added/removed/stale since the last call.
+func (w *processWatcher) Stats() map[string]int64 {
+	return map[string]int64{
+		"docker_proxy_added":   atomic.SwapInt64(&w.added, 0),
+		"docker_proxy_removed": atomic.SwapInt64(&w.removed, 0),
+		"docker_proxy_stale":   atomic.SwapInt64(&w.stale, 0),
+	}
+}
+
+// reapStale drops every tracked proxy whose process has exited, or whose PID
+// has been reused by an unrelated process (detected via a starttime
+// mismatch).
+func (w *processWatcher) reapStale() {
+	w.filter.mux.Lock()
+	defer w.filter.mux.Unlock()
+
+	// proxyByTarget holds every tracked proxy; proxyByPID loses a proxy as
+	// soon as Filter learns its IP, so it can't be the one iterated here.
+	for _, p := range w.filter.proxyByTarget {
+		pid := p.pid
+		startTime, alive := processStartTime(pid)
+		if !alive {
+			delete(w.filter.proxyByPID, pid)
+			delete(w.filter.proxyByTarget, p.target)
+			log.Debugf("docker-proxy pid=%d exited, dropping", pid)
+			atomic.AddInt64(&w.removed, 1)
+			continue
+		}
+
+		// p.startTime == 0 means we failed to read it at discovery time, so
+		// we can't detect PID reuse for this proxy - fall back to treating
+		// it as alive as long as the PID itself still resolves to a process.
+		if p.startTime == 0 || startTime == p.startTime {
+			continue
+		}
+
+		// Same PID, different starttime: our proxy is gone and the PID was
+		// recycled by something else.
+		delete(w.filter.proxyByPID, pid)
+		delete(w.filter.proxyByTarget, p.target)
+		log.Debugf("docker-proxy pid=%d starttime changed, PID was reused, dropping stale entry", pid)
+		atomic.AddInt64(&w.stale, 1)
+	}
+}
+
+// allProcesses is process.AllProcesses indirected through a package var so
+// tests can substitute a fixed process snapshot instead of scanning /proc.
+var allProcesses = process.AllProcesses
+
+// discoverNew rescans the running processes for docker-proxy binaries that
+// aren't tracked yet and adds them to the filter.
+func (w *processWatcher) discoverNew() {
+	procs, err := allProcesses()
+	if err != nil {
+		log.Debugf("docker-proxy watcher: error listing processes: %s", err)
+		return
+	}
+
+	w.filter.mux.Lock()
+	defer w.filter.mux.Unlock()
+
+	for pid, p := range procs {
+		if _, ok := w.filter.proxyByPID[pid]; ok {
+			continue
+		}
+
+		proxy := extractProxyTarget(p)
+		if proxy == nil {
+			continue
+		}
+
+		// proxyByPID is deleted for a proxy the moment Filter discovers its
+		// IP (see the comment on Filter.proxyByPID) - from then on the
+		// durable record lives only in proxyByTarget, so without this check
+		// we'd clobber its already-learned .ip with a fresh, empty one on
+		// every tick.
+		if _, ok := w.filter.proxyByTarget[proxy.target]; ok {
+			continue
+		}
+
+		log.Debugf("detected new docker-proxy with pid=%d target.ip=%s target.port=%d target.proto=%s",
+			proxy.pid,
+			proxy.target.ip,
+			proxy.target.port,
+			proxy.target.proto,
+		)
+
+		w.filter.proxyByPID[proxy.pid] = proxy
+		w.filter.proxyByTarget[proxy.target] = proxy
+		atomic.AddInt64(&w.added, 1)
+	}
+}
+
+// processStartTime reads the starttime field (in clock ticks since boot)
+// out of /proc/<pid>/stat. It's used instead of a bare PID comparison so a
+// PID getting reused by a different process doesn't look like our original
+// docker-proxy is still alive.
+func processStartTime(pid int32) (startTime uint64, alive bool) {
+	data, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return 0, false
+	}
+
+	// The comm field (2nd field) is parenthesized and may itself contain
+	// spaces or parens, so skip past the last ')' rather than splitting
+	// naively on whitespace.
+	closeParen := strings.LastIndexByte(string(data), ')')
+	if closeParen < 0 || closeParen+2 >= len(data) {
+		return 0, false
+	}
+
+	// Fields after the comm field, 1-indexed from state(3) in `man 5 proc`:
+	// state(3) ppid(4) pgrp(5) session(6) tty_nr(7) tpgid(8) flags(9)
+	// minflt(10) cminflt(11) majflt(12) cmajflt(13) utime(14) stime(15)
+	// cutime(16) cstime(17) priority(18) nice(19) num_threads(20)
+	// itrealvalue(21) starttime(22) -> index 19 (0-indexed) in this slice.
+	fields := strings.Fields(string(data[closeParen+2:]))
+	const starttimeIdx = 19
+	if len(fields) <= starttimeIdx {
+		return 0, false
+	}
+
+	startTime, err = strconv.ParseUint(fields[starttimeIdx], 10, 64)
+	if err != nil {
+		return 0, false
+	}
+
+	return startTime, true
+}
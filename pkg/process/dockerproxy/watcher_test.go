@@ -0,0 +1,175 @@
+// +build !windows
+
+package dockerproxy
+
+import (
+	"os"
+	"testing"
+
+	"github.com/DataDog/gopsutil/process"
+)
+
+func newTestFilter() *Filter {
+	return &Filter{
+		proxyByPID:    make(map[int32]*proxy),
+		proxyByTarget: make(map[target]*proxy),
+	}
+}
+
+func TestProcessStartTimeSelf(t *testing.T) {
+	startTime, alive := processStartTime(int32(os.Getpid()))
+	if !alive {
+		t.Fatalf("expected the current process to be reported alive")
+	}
+	if startTime == 0 {
+		t.Fatalf("expected a non-zero starttime for the current process")
+	}
+}
+
+func TestProcessStartTimeMissingPID(t *testing.T) {
+	// No real system assigns a PID this high - simulate an exited/nonexistent
+	// process without depending on any particular PID being free.
+	_, alive := processStartTime(1 << 30)
+	if alive {
+		t.Fatalf("expected a nonexistent PID to be reported as not alive")
+	}
+}
+
+func TestReapStaleDropsExitedProxy(t *testing.T) {
+	f := newTestFilter()
+	w := &processWatcher{filter: f}
+
+	tgt := target{ip: "172.17.0.2", port: 80}
+	p := &proxy{pid: 1 << 30, target: tgt}
+	f.proxyByPID[p.pid] = p
+	f.proxyByTarget[tgt] = p
+
+	w.reapStale()
+
+	if _, ok := f.proxyByPID[p.pid]; ok {
+		t.Fatalf("expected exited proxy to be dropped from proxyByPID")
+	}
+	if _, ok := f.proxyByTarget[tgt]; ok {
+		t.Fatalf("expected exited proxy to be dropped from proxyByTarget")
+	}
+	if w.removed != 1 {
+		t.Fatalf("removed = %d, want 1", w.removed)
+	}
+}
+
+// TestReapStaleKeepsAliveProxyWithUnknownStartTime covers the case documented
+// on proxy.startTime: when we failed to read it at discovery time (left at
+// zero), reapStale must fall back to plain liveness instead of treating the
+// unknown startTime as a mismatch.
+func TestReapStaleKeepsAliveProxyWithUnknownStartTime(t *testing.T) {
+	f := newTestFilter()
+	w := &processWatcher{filter: f}
+
+	pid := int32(os.Getpid())
+	tgt := target{ip: "172.17.0.3", port: 443}
+	p := &proxy{pid: pid, target: tgt}
+	f.proxyByPID[pid] = p
+	f.proxyByTarget[tgt] = p
+
+	w.reapStale()
+
+	if _, ok := f.proxyByPID[pid]; !ok {
+		t.Fatalf("expected a still-alive proxy with unknown startTime to be kept")
+	}
+	if w.removed != 0 || w.stale != 0 {
+		t.Fatalf("expected no removal/staleness counted, got removed=%d stale=%d", w.removed, w.stale)
+	}
+}
+
+func TestReapStaleDropsProxyWhoseStartTimeChanged(t *testing.T) {
+	f := newTestFilter()
+	w := &processWatcher{filter: f}
+
+	pid := int32(os.Getpid())
+	realStartTime, alive := processStartTime(pid)
+	if !alive {
+		t.Fatalf("expected to read our own startTime")
+	}
+
+	tgt := target{ip: "172.17.0.4", port: 8080}
+	p := &proxy{pid: pid, target: tgt, startTime: realStartTime + 1}
+	f.proxyByPID[pid] = p
+	f.proxyByTarget[tgt] = p
+
+	w.reapStale()
+
+	if _, ok := f.proxyByPID[pid]; ok {
+		t.Fatalf("expected proxy with mismatched startTime (PID reuse) to be dropped")
+	}
+	if w.stale != 1 {
+		t.Fatalf("stale = %d, want 1", w.stale)
+	}
+}
+
+func TestDiscoverNewIgnoresNonProxyProcesses(t *testing.T) {
+	f := newTestFilter()
+	w := &processWatcher{filter: f}
+
+	// No docker-proxy runs in a normal test environment, so discoverNew
+	// should leave the filter empty rather than misidentifying an unrelated
+	// process as one.
+	w.discoverNew()
+
+	if len(f.proxyByPID) != 0 {
+		t.Fatalf("expected no proxies discovered, got %d", len(f.proxyByPID))
+	}
+}
+
+// TestDiscoverNewPreservesIPLearnedByFilter covers the interaction between
+// Filter.Filter (which deletes a proxy from proxyByPID the moment its IP is
+// discovered, leaving proxyByTarget as the only durable record) and
+// discoverNew: rediscovering the same still-running proxy must not
+// overwrite the already-learned .ip with a fresh, empty one.
+func TestDiscoverNewPreservesIPLearnedByFilter(t *testing.T) {
+	f := newTestFilter()
+	w := &processWatcher{filter: f}
+
+	pid := int32(os.Getpid())
+	tgt := target{ip: "172.17.0.5", port: 80}
+	p := &proxy{pid: pid, target: tgt, ip: "172.17.0.100"}
+
+	// Simulate the state right after Filter.Filter discovered p's IP: the
+	// proxy is gone from proxyByPID, but still tracked by target.
+	f.proxyByTarget[tgt] = p
+
+	origAllProcesses := allProcesses
+	allProcesses = func() (map[int32]*process.FilledProcess, error) {
+		return map[int32]*process.FilledProcess{
+			pid: {
+				Pid:     pid,
+				Cmdline: []string{"/usr/bin/docker-proxy", "-container-ip", tgt.ip, "-container-port", "80"},
+			},
+		}, nil
+	}
+	defer func() { allProcesses = origAllProcesses }()
+
+	w.discoverNew()
+
+	got, ok := f.proxyByTarget[tgt]
+	if !ok {
+		t.Fatalf("expected proxy to still be tracked by target")
+	}
+	if got.ip != "172.17.0.100" {
+		t.Fatalf("ip = %q, want %q (discoverNew must not clobber an already-learned ip)", got.ip, "172.17.0.100")
+	}
+	if w.added != 0 {
+		t.Fatalf("added = %d, want 0 (rediscovering a known target shouldn't count as a new proxy)", w.added)
+	}
+}
+
+func TestFilterStatsForwardsWatcherCounters(t *testing.T) {
+	f := newTestFilter()
+	w := &processWatcher{filter: f}
+	w.added = 2
+	f.watcher = w
+
+	stats := f.Stats()
+	if stats["docker_proxy_added"] != 2 {
+		t.Fatalf("docker_proxy_added = %d, want 2", stats["docker_proxy_added"])
+	}
+}